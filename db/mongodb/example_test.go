@@ -3,7 +3,15 @@ package mongodb_test
 import (
 	"context"
 	"fmt"
-	"leaf/db/mongodb"
+	"testing"
+	"time"
+
+	"github.com/name5566/leaf/db/mongodb"
+	"github.com/name5566/leaf/db/mongodb/migrate"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 func Example() {
@@ -50,3 +58,366 @@ func Example() {
 	// 2
 	// 3
 }
+
+func ExampleConnectionContext_WithTransaction() {
+	c, err := mongodb.Connect("mongodb://localhost:27017", 10)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer c.Close()
+
+	// Operations performed inside fn must go through the session's own
+	// client, obtained via mongo.SessionFromContext(sessCtx), so they are
+	// pinned to the same connection as the transaction.
+	_, err = c.WithTransaction(context.TODO(), func(sessCtx context.Context) (interface{}, error) {
+		accounts := mongo.SessionFromContext(sessCtx).Client().Database("test").Collection("accounts")
+		_, err := accounts.UpdateOne(sessCtx,
+			map[string]interface{}{"_id": "alice"},
+			map[string]interface{}{"$inc": map[string]interface{}{"balance": -10}},
+			options.UpdateOne().SetUpsert(true),
+		)
+		return nil, err
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println("ok")
+	// Output:
+	// ok
+}
+
+type widget struct {
+	ID   string `bson:"_id"`
+	Name string `bson:"name"`
+}
+
+func ExampleTyped() {
+	c, err := mongodb.Connect("mongodb://localhost:27017", 10)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer c.Close()
+
+	widgets := mongodb.Typed[widget](c, "test", "widgets")
+
+	_, err = widgets.InsertOne(context.TODO(), widget{ID: "w1", Name: "sprocket"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	w, err := widgets.FindOne(context.TODO(), bson.M{"_id": "w1"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println(w.Name)
+	// Output:
+	// sprocket
+}
+
+type countingObserver struct {
+	refs int
+}
+
+func (o *countingObserver) OnRef(waitDur time.Duration, poolSize int) { o.refs++ }
+func (o *countingObserver) OnPingFail(err error)                      {}
+func (o *countingObserver) OnReconnect()                              {}
+
+func ExampleConnectionContext_Stats() {
+	c, err := mongodb.Connect("mongodb://localhost:27017", 10)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer c.Close()
+
+	obs := &countingObserver{}
+	c.SetObserver(obs)
+
+	s, err := c.Ref()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	c.UnRef(s)
+
+	stats := c.Stats()
+	fmt.Println(obs.refs, len(stats.RefsPerClient))
+	// Output:
+	// 1 10
+}
+
+func ExampleConnectionContext_NextSeqContext() {
+	c, err := mongodb.Connect("mongodb://localhost:27017", 10)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = c.EnsureCounterContext(ctx, "test", "counters", "ctx-test")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	id, err := c.NextSeqContext(ctx, "test", "counters", "ctx-test")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println(id)
+	// Output:
+	// 1
+}
+
+func ExampleConnectionContext_EnsureIndexSpec() {
+	c, err := mongodb.Connect("mongodb://localhost:27017", 10)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer c.Close()
+
+	// TTL index: expire session documents 30 minutes after createdAt.
+	err = c.EnsureIndexSpec("test", "sessions", mongodb.IndexSpec{
+		Keys:        []mongodb.IndexKey{{Name: "createdAt", Direction: 1}},
+		ExpireAfter: 30 * time.Minute,
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// Compound index with a descending key.
+	err = c.EnsureIndexSpec("test", "sessions", mongodb.IndexSpec{
+		Keys: []mongodb.IndexKey{
+			{Name: "userID", Direction: 1},
+			{Name: "createdAt", Direction: -1},
+		},
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println("ok")
+	// Output:
+	// ok
+}
+
+func ExampleCollection_Find() {
+	c, err := mongodb.Connect("mongodb://localhost:27017", 10)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer c.Close()
+
+	widgets := mongodb.Typed[widget](c, "test", "widgets")
+
+	_, err = widgets.BulkWrite(context.TODO(), []mongo.WriteModel{
+		mongo.NewInsertOneModel().SetDocument(widget{ID: "f1", Name: "bolt"}),
+		mongo.NewInsertOneModel().SetDocument(widget{ID: "f2", Name: "nut"}),
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	it, err := widgets.Find(context.TODO(), bson.M{"_id": bson.M{"$in": bson.A{"f1", "f2"}}})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer it.Close(context.TODO())
+
+	count := 0
+	for it.Next(context.TODO()) {
+		if _, err := it.Decode(); err != nil {
+			fmt.Println(err)
+			return
+		}
+		count++
+	}
+	if err := it.Err(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println(count)
+	// Output:
+	// 2
+}
+
+func ExampleCollection_UpdateOne() {
+	c, err := mongodb.Connect("mongodb://localhost:27017", 10)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer c.Close()
+
+	widgets := mongodb.Typed[widget](c, "test", "widgets")
+
+	_, err = widgets.InsertOne(context.TODO(), widget{ID: "u1", Name: "sprocket"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	_, err = widgets.UpdateOne(context.TODO(),
+		bson.M{"_id": "u1"},
+		bson.M{"$set": bson.M{"name": "gear"}},
+	)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	w, err := widgets.FindOne(context.TODO(), bson.M{"_id": "u1"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println(w.Name)
+	// Output:
+	// gear
+}
+
+func ExampleCollection_DeleteOne() {
+	c, err := mongodb.Connect("mongodb://localhost:27017", 10)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer c.Close()
+
+	widgets := mongodb.Typed[widget](c, "test", "widgets")
+
+	_, err = widgets.InsertOne(context.TODO(), widget{ID: "d1", Name: "washer"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	res, err := widgets.DeleteOne(context.TODO(), bson.M{"_id": "d1"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println(res.DeletedCount)
+	// Output:
+	// 1
+}
+
+func ExampleCollection_Watch() {
+	c, err := mongodb.Connect("mongodb://localhost:27017", 10)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer c.Close()
+
+	widgets := mongodb.Typed[widget](c, "test", "widgets")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	events, err := widgets.Watch(ctx, mongo.Pipeline{})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	_, err = widgets.InsertOne(ctx, widget{ID: "w2", Name: "cog"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	ev := <-events
+	fmt.Println(ev.OperationType, ev.FullDocument.Name)
+	// Output:
+	// insert cog
+}
+
+// TestCollection_WatchContextCancel asserts that canceling the context
+// passed to Watch closes the returned channel and releases the client Watch
+// acquired via Ref, so a canceled subscription doesn't leak a pool slot.
+func TestCollection_WatchContextCancel(t *testing.T) {
+	c, err := mongodb.Connect("mongodb://localhost:27017", 10)
+	if err != nil {
+		t.Skipf("no mongod reachable: %v", err)
+	}
+	defer c.Close()
+
+	widgets := mongodb.Typed[widget](c, "test", "widgets")
+
+	before := c.Stats()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := widgets.Watch(ctx, mongo.Pipeline{})
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	cancel()
+
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatal("events channel delivered a value after ctx was canceled, want it closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("events channel did not close within 5s of ctx being canceled")
+	}
+
+	after := c.Stats()
+	for i, refs := range after.RefsPerClient {
+		if refs != before.RefsPerClient[i] {
+			t.Fatalf("client %d has %d refs after Watch's ctx was canceled, want %d (client leaked)", i, refs, before.RefsPerClient[i])
+		}
+	}
+}
+
+func ExampleConnectionContext_Migrate() {
+	c, err := mongodb.Connect("mongodb://localhost:27017", 10)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer c.Close()
+
+	migrations := []migrate.Migration{
+		{
+			Version: 1,
+			Up: func(ctx context.Context, client *mongo.Client) error {
+				coll := client.Database("test").Collection("widgets")
+				return migrate.CreateIndex(ctx, coll, bson.D{{Key: "name", Value: 1}}, nil)
+			},
+		},
+	}
+
+	err = c.Migrate(context.TODO(), "test", migrations)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println("ok")
+	// Output:
+	// ok
+}
@@ -0,0 +1,79 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// IndexKey is a single field of an index, together with its sort direction
+// or special index type.
+type IndexKey struct {
+	Name string
+	// Direction is 1 (ascending), -1 (descending), "text", or "2dsphere".
+	Direction interface{}
+}
+
+// IndexSpec describes an index beyond the simple "ascending keys, sparse"
+// shape EnsureIndex/EnsureUniqueIndex cover.
+type IndexSpec struct {
+	Keys          []IndexKey
+	Unique        bool
+	Sparse        bool
+	PartialFilter bson.M
+	// ExpireAfter, if positive, creates a TTL index that expires documents
+	// ExpireAfter after the time stored in the first key.
+	ExpireAfter time.Duration
+	Name        string
+	Collation   *options.Collation
+}
+
+// goroutine safe
+//
+// EnsureIndexSpec creates an index from spec, supporting compound keys with
+// mixed directions, text/2dsphere keys, partial filters, TTL expiry and
+// collations. EnsureIndex and EnsureUniqueIndex are thin wrappers around it
+// for the common case of a sparse index over ascending keys.
+func (c *ConnectionContext) EnsureIndexSpec(db, collection string, spec IndexSpec) error {
+	return c.EnsureIndexSpecContext(context.Background(), db, collection, spec)
+}
+
+// goroutine safe
+func (c *ConnectionContext) EnsureIndexSpecContext(ctx context.Context, db, collection string, spec IndexSpec) error {
+	s, err := c.RefContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.UnRef(s)
+
+	collectionRef := s.Database(db).Collection(collection)
+
+	keysDoc := make(bson.D, len(spec.Keys))
+	for i, k := range spec.Keys {
+		keysDoc[i] = bson.E{Key: k.Name, Value: k.Direction}
+	}
+
+	indexOpts := options.Index().SetUnique(spec.Unique).SetSparse(spec.Sparse)
+	if spec.PartialFilter != nil {
+		indexOpts = indexOpts.SetPartialFilterExpression(spec.PartialFilter)
+	}
+	if spec.ExpireAfter > 0 {
+		indexOpts = indexOpts.SetExpireAfterSeconds(int32(spec.ExpireAfter.Seconds()))
+	}
+	if spec.Name != "" {
+		indexOpts = indexOpts.SetName(spec.Name)
+	}
+	if spec.Collation != nil {
+		indexOpts = indexOpts.SetCollation(spec.Collation)
+	}
+
+	indexModel := mongo.IndexModel{
+		Keys:    keysDoc,
+		Options: indexOpts,
+	}
+	_, err = collectionRef.Indexes().CreateOne(ctx, indexModel)
+	return err
+}
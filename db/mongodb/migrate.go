@@ -0,0 +1,23 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/name5566/leaf/db/mongodb/migrate"
+)
+
+// goroutine safe
+//
+// Migrate applies migrations against db that have not yet run, recording
+// progress in db's schema_migrations collection. See package
+// db/mongodb/migrate for the Migration type and migration helpers
+// (CreateCollection, CreateIndex, DropIndex, RenameField, Backfill).
+func (c *ConnectionContext) Migrate(ctx context.Context, db string, migrations []migrate.Migration) error {
+	s, err := c.RefContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.UnRef(s)
+
+	return migrate.Run(ctx, s.Client, db, migrations)
+}
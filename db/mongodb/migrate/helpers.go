@@ -0,0 +1,82 @@
+package migrate
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// CreateCollection creates collection in db, applying validator as its
+// JSON schema validator when non-nil.
+func CreateCollection(ctx context.Context, db *mongo.Database, collection string, validator bson.M) error {
+	opts := options.CreateCollection()
+	if validator != nil {
+		opts = opts.SetValidator(validator)
+	}
+	return db.CreateCollection(ctx, collection, opts)
+}
+
+// CreateIndex creates an index described by keys (e.g. bson.D{{Key: "email", Value: 1}})
+// on collection. opts may be nil.
+func CreateIndex(ctx context.Context, coll *mongo.Collection, keys bson.D, opts *options.IndexOptionsBuilder) error {
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: keys, Options: opts})
+	return err
+}
+
+// DropIndex drops the named index from collection.
+func DropIndex(ctx context.Context, coll *mongo.Collection, name string) error {
+	return coll.Indexes().DropOne(ctx, name)
+}
+
+// RenameField renames field from to to, across every document in
+// collection matching filter. A nil filter renames the field everywhere.
+func RenameField(ctx context.Context, coll *mongo.Collection, filter bson.M, from, to string) error {
+	if filter == nil {
+		filter = bson.M{}
+	}
+	_, err := coll.UpdateMany(ctx, filter, bson.M{"$rename": bson.M{from: to}})
+	return err
+}
+
+// Backfill walks every document in collection matching filter in batches
+// of batchSize, passing each to update. update returns the fields to $set
+// on the document, or a nil map to leave it untouched. It is meant for
+// migrations that need to compute a new field from existing data, where a
+// single server-side update can't express the change.
+func Backfill(ctx context.Context, coll *mongo.Collection, filter bson.M, batchSize int, update func(ctx context.Context, doc bson.M) (bson.M, error)) error {
+	if filter == nil {
+		filter = bson.M{}
+	}
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	cursor, err := coll.Find(ctx, filter, options.Find().SetBatchSize(int32(batchSize)))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+
+		changes, err := update(ctx, doc)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			continue
+		}
+
+		if _, err := coll.UpdateOne(ctx, bson.M{"_id": doc["_id"]}, bson.M{"$set": changes}); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
@@ -0,0 +1,129 @@
+// Package migrate implements a schema migration runner for MongoDB,
+// modeled after the mender deviceconnect migration pattern: callers
+// register an ordered list of Migration steps and Run applies whichever
+// ones have not yet been recorded as applied, guarding the whole run with
+// a lock document so concurrent runners don't apply migrations twice.
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/name5566/leaf/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	collectionName = "schema_migrations"
+	lockID         = "lock"
+	lockRetryDelay = time.Second
+)
+
+// Migration is a single, ordered schema change.
+type Migration struct {
+	// Version identifies the migration and determines its run order.
+	// Versions must be unique within a registered set.
+	Version int64
+	// Up applies the migration against client.
+	Up func(ctx context.Context, client *mongo.Client) error
+}
+
+type appliedVersion struct {
+	ID        int64     `bson:"_id"`
+	AppliedAt time.Time `bson:"appliedAt"`
+}
+
+// Run applies migrations that have not yet been recorded as applied in
+// db's schema_migrations collection, in ascending Version order. It holds
+// a lock document in the same collection for the duration of the run so
+// that concurrent runners don't apply migrations twice.
+func Run(ctx context.Context, client *mongo.Client, db string, migrations []Migration) error {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	coll := client.Database(db).Collection(collectionName)
+
+	if err := acquireLock(ctx, coll); err != nil {
+		return fmt.Errorf("migrate: acquire lock: %w", err)
+	}
+	// Release with a fresh context rather than ctx: if ctx is what caused a
+	// migration to fail (canceled, timed out), releasing with it would fail
+	// too and wedge the lock for every future Migrate call on this db.
+	defer func() {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), lockRetryDelay)
+		defer cancel()
+		if err := releaseLock(releaseCtx, coll); err != nil {
+			log.Error("migrate: release lock failed: %v", err)
+		}
+	}()
+
+	for _, m := range sorted {
+		applied, err := isApplied(ctx, coll, m.Version)
+		if err != nil {
+			return fmt.Errorf("migrate: check version %d: %w", m.Version, err)
+		}
+		if applied {
+			continue
+		}
+
+		if err := m.Up(ctx, client); err != nil {
+			return fmt.Errorf("migrate: version %d failed: %w", m.Version, err)
+		}
+
+		_, err = coll.InsertOne(ctx, appliedVersion{ID: m.Version, AppliedAt: time.Now()})
+		if err != nil {
+			return fmt.Errorf("migrate: recording version %d: %w", m.Version, err)
+		}
+	}
+
+	return nil
+}
+
+func isApplied(ctx context.Context, coll *mongo.Collection, version int64) (bool, error) {
+	err := coll.FindOne(ctx, bson.M{"_id": version}).Err()
+	switch err {
+	case nil:
+		return true, nil
+	case mongo.ErrNoDocuments:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// acquireLock takes the lock document, blocking until it is free or ctx is
+// done. The lock is stored as a document with _id == lockID in the same
+// collection as the applied versions, so no extra collection is needed.
+func acquireLock(ctx context.Context, coll *mongo.Collection) error {
+	for {
+		_, err := coll.UpdateOne(ctx,
+			bson.M{"_id": lockID, "locked": bson.M{"$ne": true}},
+			bson.M{"$set": bson.M{"locked": true, "lockedAt": time.Now()}},
+			options.UpdateOne().SetUpsert(true),
+		)
+		switch {
+		case err == nil:
+			return nil
+		case mongo.IsDuplicateKeyError(err):
+			// another runner holds the lock
+		default:
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockRetryDelay):
+		}
+	}
+}
+
+func releaseLock(ctx context.Context, coll *mongo.Collection) error {
+	_, err := coll.UpdateOne(ctx, bson.M{"_id": lockID}, bson.M{"$set": bson.M{"locked": false}})
+	return err
+}
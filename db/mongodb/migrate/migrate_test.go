@@ -0,0 +1,92 @@
+package migrate_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/name5566/leaf/db/mongodb/migrate"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const testDB = "test"
+
+func dialTest(t *testing.T) *mongo.Client {
+	t.Helper()
+
+	client, err := mongo.Connect(options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if err := client.Ping(context.Background(), nil); err != nil {
+		t.Skipf("no mongod reachable: %v", err)
+	}
+	return client
+}
+
+func TestRunSkipsAppliedVersions(t *testing.T) {
+	client := dialTest(t)
+	defer client.Disconnect(context.Background())
+
+	coll := client.Database(testDB).Collection("schema_migrations")
+	if err := coll.Drop(context.Background()); err != nil {
+		t.Fatalf("drop schema_migrations: %v", err)
+	}
+
+	var runs int32
+	migrations := []migrate.Migration{
+		{
+			Version: 1,
+			Up: func(ctx context.Context, client *mongo.Client) error {
+				atomic.AddInt32(&runs, 1)
+				return nil
+			},
+		},
+	}
+
+	if err := migrate.Run(context.Background(), client, testDB, migrations); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+	if err := migrate.Run(context.Background(), client, testDB, migrations); err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+
+	if got := atomic.LoadInt32(&runs); got != 1 {
+		t.Fatalf("Up ran %d times, want 1 (the second Run should have skipped the already-applied version)", got)
+	}
+}
+
+func TestRunBlocksOnHeldLock(t *testing.T) {
+	client := dialTest(t)
+	defer client.Disconnect(context.Background())
+
+	coll := client.Database(testDB).Collection("schema_migrations")
+	if err := coll.Drop(context.Background()); err != nil {
+		t.Fatalf("drop schema_migrations: %v", err)
+	}
+
+	// Hold the lock document ourselves, as a concurrent runner would.
+	_, err := coll.InsertOne(context.Background(), bson.M{
+		"_id":      "lock",
+		"locked":   true,
+		"lockedAt": time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("seed lock: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err = migrate.Run(ctx, client, testDB, nil)
+	if err == nil {
+		t.Fatal("Run succeeded despite the lock document being held by another runner")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run failed for a reason other than waiting out the held lock: %v", err)
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"container/heap"
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/name5566/leaf/log"
 	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/event"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
@@ -53,6 +55,10 @@ type ConnectionContext struct {
 	sync.Mutex
 	clients    ClientHeap
 	clientOpts *options.ClientOptions
+
+	observer     Observer
+	reconnects   int64
+	pingFailures int64
 }
 
 // goroutine safe
@@ -62,6 +68,17 @@ func Connect(url string, clientNum int) (*ConnectionContext, error) {
 
 // goroutine safe
 func ConnectWithTimeout(url string, clientNum int, connectTimeout, timeout time.Duration) (*ConnectionContext, error) {
+	return ConnectWithMonitor(url, clientNum, connectTimeout, timeout, nil)
+}
+
+// goroutine safe
+//
+// ConnectWithMonitor is ConnectWithTimeout, but additionally installs
+// monitor as the driver's CommandMonitor on every pooled client, so
+// CommandStarted/Succeeded/Failed events can be forwarded to Prometheus,
+// OpenTelemetry, or similar. Pass nil for the same behavior as
+// ConnectWithTimeout.
+func ConnectWithMonitor(url string, clientNum int, connectTimeout, timeout time.Duration, monitor *event.CommandMonitor) (*ConnectionContext, error) {
 	if clientNum <= 0 {
 		clientNum = 100
 		log.Release("invalid clientNum, reset to %v", clientNum)
@@ -71,7 +88,8 @@ func ConnectWithTimeout(url string, clientNum int, connectTimeout, timeout time.
 		clientOpts: options.Client().
 			ApplyURI(url).
 			SetConnectTimeout(connectTimeout).
-			SetTimeout(timeout),
+			SetTimeout(timeout).
+			SetMonitor(monitor),
 		clients: make(ClientHeap, 0, clientNum),
 	}
 
@@ -101,24 +119,66 @@ func (c *ConnectionContext) Close() {
 
 // goroutine safe
 func (c *ConnectionContext) Ref() (*Client, error) {
+	return c.RefContext(context.Background())
+}
+
+// goroutine safe
+//
+// RefContext is Ref, but honors ctx for the Ping/Disconnect it may perform
+// to refresh a stale client, so a stuck server doesn't block the caller
+// forever even though SetTimeout is set on clientOpts. The mongo.Connect
+// that replaces a stale client takes no context, so ctx does not apply to it.
+func (c *ConnectionContext) RefContext(ctx context.Context) (*Client, error) {
+	start := time.Now()
+
 	c.Lock()
-	defer c.Unlock()
+
+	var pingErr error
+	reconnected := false
 
 	s := c.clients[0]
 	if s.ref == 0 {
 		// Refresh the client connection only when ref is 0, as it indicates the client is not in use.
-		if err := s.Ping(context.Background(), nil); err != nil {
-			s.Disconnect(context.Background())
+		if err := s.Ping(ctx, nil); err != nil {
+			pingErr = err
+			atomic.AddInt64(&c.pingFailures, 1)
+
+			s.Disconnect(ctx)
 			newClient, err := mongo.Connect(c.clientOpts)
 			if err != nil {
+				observer := c.observer
+				c.Unlock()
+				if observer != nil {
+					observer.OnPingFail(pingErr)
+				}
 				return nil, err
 			}
 			s.Client = newClient
+
+			reconnected = true
+			atomic.AddInt64(&c.reconnects, 1)
 		}
 	}
 	s.ref++
 	heap.Fix(&c.clients, 0)
 
+	observer := c.observer
+	poolSize := len(c.clients)
+	c.Unlock()
+
+	// Observer callbacks run outside the pool lock so a slow sink (e.g. a
+	// metrics/OTel backend over the network) can't stall every other
+	// goroutine trying to Ref/UnRef a client.
+	if observer != nil {
+		if pingErr != nil {
+			observer.OnPingFail(pingErr)
+		}
+		if reconnected {
+			observer.OnReconnect()
+		}
+		observer.OnRef(time.Since(start), poolSize)
+	}
+
 	return s, nil
 }
 
@@ -136,14 +196,19 @@ func (c *ConnectionContext) UnRef(s *Client) {
 
 // goroutine safe
 func (c *ConnectionContext) EnsureCounter(db, collection, id string) error {
-	s, err := c.Ref()
+	return c.EnsureCounterContext(context.Background(), db, collection, id)
+}
+
+// goroutine safe
+func (c *ConnectionContext) EnsureCounterContext(ctx context.Context, db, collection, id string) error {
+	s, err := c.RefContext(ctx)
 	if err != nil {
 		return err
 	}
 	defer c.UnRef(s)
 
 	collectionRef := s.Database(db).Collection(collection)
-	_, err = collectionRef.InsertOne(context.Background(), bson.M{
+	_, err = collectionRef.InsertOne(ctx, bson.M{
 		"_id": id,
 		"seq": 0,
 	})
@@ -156,7 +221,12 @@ func (c *ConnectionContext) EnsureCounter(db, collection, id string) error {
 
 // goroutine safe
 func (c *ConnectionContext) NextSeq(db, collection, id string) (int, error) {
-	s, err := c.Ref()
+	return c.NextSeqContext(context.Background(), db, collection, id)
+}
+
+// goroutine safe
+func (c *ConnectionContext) NextSeqContext(ctx context.Context, db, collection, id string) (int, error) {
+	s, err := c.RefContext(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -170,38 +240,35 @@ func (c *ConnectionContext) NextSeq(db, collection, id string) (int, error) {
 	var res struct {
 		Seq int `bson:"seq"`
 	}
-	err = collectionRef.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&res)
+	err = collectionRef.FindOneAndUpdate(ctx, filter, update, opts).Decode(&res)
 
 	return res.Seq, err
 }
 
 // goroutine safe
 func (c *ConnectionContext) EnsureIndex(db, collection string, key []string) error {
-	return c.ensureIndex(db, collection, key, false)
+	return c.EnsureIndexContext(context.Background(), db, collection, key)
+}
+
+// goroutine safe
+func (c *ConnectionContext) EnsureIndexContext(ctx context.Context, db, collection string, key []string) error {
+	return c.EnsureIndexSpecContext(ctx, db, collection, IndexSpec{Keys: ascendingKeys(key), Sparse: true})
 }
 
 // goroutine safe
 func (c *ConnectionContext) EnsureUniqueIndex(db, collection string, key []string) error {
-	return c.ensureIndex(db, collection, key, true)
+	return c.EnsureUniqueIndexContext(context.Background(), db, collection, key)
 }
 
-func (c *ConnectionContext) ensureIndex(db, collection string, key []string, unique bool) error {
-	s, err := c.Ref()
-	if err != nil {
-		return err
-	}
-	defer c.UnRef(s)
+// goroutine safe
+func (c *ConnectionContext) EnsureUniqueIndexContext(ctx context.Context, db, collection string, key []string) error {
+	return c.EnsureIndexSpecContext(ctx, db, collection, IndexSpec{Keys: ascendingKeys(key), Unique: true, Sparse: true})
+}
 
-	collectionRef := s.Database(db).Collection(collection)
-	keysDoc := make(bson.D, len(key))
+func ascendingKeys(key []string) []IndexKey {
+	keys := make([]IndexKey, len(key))
 	for i, k := range key {
-		keysDoc[i] = bson.E{Key: k, Value: 1}
-	}
-
-	indexModel := mongo.IndexModel{
-		Keys:    keysDoc,
-		Options: options.Index().SetUnique(unique).SetSparse(true),
+		keys[i] = IndexKey{Name: k, Direction: 1}
 	}
-	_, err = collectionRef.Indexes().CreateOne(context.Background(), indexModel)
-	return err
+	return keys
 }
@@ -0,0 +1,65 @@
+package mongodb
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Observer lets callers hook into pool-level events for metrics or
+// tracing. Callbacks may be invoked concurrently with each other and with
+// pool operations, and must not block or call back into the
+// ConnectionContext.
+type Observer interface {
+	// OnRef is called after Ref/RefContext hands out a client, with how
+	// long the call took (waiting for the pool lock plus any health check
+	// and reconnect) and the pool's size.
+	OnRef(waitDur time.Duration, poolSize int)
+	// OnPingFail is called when the health check Ref runs before reusing
+	// an idle client fails.
+	OnPingFail(err error)
+	// OnReconnect is called after a client is successfully reconnected
+	// following a failed ping.
+	OnReconnect()
+}
+
+// goroutine safe
+//
+// SetObserver installs o to receive pool events, replacing any previously
+// installed Observer. Pass nil to stop receiving events.
+func (c *ConnectionContext) SetObserver(o Observer) {
+	c.Lock()
+	defer c.Unlock()
+	c.observer = o
+}
+
+// PoolStats summarizes the state of a ConnectionContext's client pool.
+type PoolStats struct {
+	// RefsPerClient holds the current ref count of every pooled client.
+	RefsPerClient []int
+	// Reconnects counts clients replaced after a failed ping.
+	Reconnects int64
+	// PingFailures counts failed pings, including ones that led to a
+	// successful reconnect.
+	PingFailures int64
+}
+
+// goroutine safe
+//
+// Stats reports the current ref distribution across the pool, along with
+// cumulative reconnect and ping-failure counts, so operators can judge
+// whether clientNum is sized correctly for their load.
+func (c *ConnectionContext) Stats() PoolStats {
+	c.Lock()
+	defer c.Unlock()
+
+	refs := make([]int, len(c.clients))
+	for i, cl := range c.clients {
+		refs[i] = cl.ref
+	}
+
+	return PoolStats{
+		RefsPerClient: refs,
+		Reconnects:    atomic.LoadInt64(&c.reconnects),
+		PingFailures:  atomic.LoadInt64(&c.pingFailures),
+	}
+}
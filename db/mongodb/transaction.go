@@ -0,0 +1,68 @@
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Session pins the Client acquired via Ref for the lifetime of a
+// multi-document transaction, so every operation performed through it hits
+// the same connection pool instead of a possibly different client chosen by
+// a later Ref call.
+type Session struct {
+	*mongo.Session
+	client *Client
+}
+
+// goroutine safe
+//
+// StartSession acquires a client via Ref and starts a driver session bound
+// to it. The caller must call EndSession to release both the session and
+// the underlying client back to the pool.
+func (c *ConnectionContext) StartSession() (*Session, error) {
+	s, err := c.Ref()
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.Client.StartSession()
+	if err != nil {
+		c.UnRef(s)
+		return nil, err
+	}
+
+	return &Session{Session: session, client: s}, nil
+}
+
+// goroutine safe
+//
+// EndSession ends the underlying driver session and releases the client
+// pinned by StartSession back to the pool.
+func (c *ConnectionContext) EndSession(ctx context.Context, sess *Session) {
+	if sess == nil {
+		return
+	}
+	sess.Session.EndSession(ctx)
+	c.UnRef(sess.client)
+}
+
+// goroutine safe
+//
+// WithTransaction runs fn inside a MongoDB multi-document transaction. It
+// acquires a client via Ref, starts a session pinned to that client so every
+// operation performed through sessCtx hits the same connection pool, and
+// releases the client once the transaction completes. Retries for the
+// TransientTransactionError and UnknownTransactionCommitResult error labels
+// are handled by the driver's session.WithTransaction, per the semantics
+// documented in the driver's own session tests.
+func (c *ConnectionContext) WithTransaction(ctx context.Context, fn func(sessCtx context.Context) (interface{}, error), opts ...options.Lister[options.TransactionOptions]) (interface{}, error) {
+	sess, err := c.StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer c.EndSession(ctx, sess)
+
+	return sess.WithTransaction(ctx, fn, opts...)
+}
@@ -0,0 +1,259 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/name5566/leaf/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// watchReopenDelay is how long Watch waits between attempts to reopen a
+// dropped change stream.
+const watchReopenDelay = time.Second
+
+// Collection is a typed wrapper around a MongoDB collection. It hides the
+// Ref/UnRef/Database(db).Collection(coll) boilerplate otherwise required
+// around every operation and decodes results directly into T.
+type Collection[T any] struct {
+	c    *ConnectionContext
+	db   string
+	coll string
+}
+
+// goroutine safe
+//
+// Typed returns a Collection bound to db/coll that decodes into T. Go does
+// not allow methods to carry their own type parameters, so this is a
+// package-level function rather than a method on *ConnectionContext.
+func Typed[T any](c *ConnectionContext, db, coll string) *Collection[T] {
+	return &Collection[T]{c: c, db: db, coll: coll}
+}
+
+func (t *Collection[T]) ref(ctx context.Context) (*Client, *mongo.Collection, error) {
+	s, err := t.c.RefContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, s.Client.Database(t.db).Collection(t.coll), nil
+}
+
+// goroutine safe
+func (t *Collection[T]) InsertOne(ctx context.Context, doc T) (*mongo.InsertOneResult, error) {
+	s, coll, err := t.ref(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer t.c.UnRef(s)
+
+	return coll.InsertOne(ctx, doc)
+}
+
+// goroutine safe
+func (t *Collection[T]) FindOne(ctx context.Context, filter bson.M) (T, error) {
+	var doc T
+
+	s, coll, err := t.ref(ctx)
+	if err != nil {
+		return doc, err
+	}
+	defer t.c.UnRef(s)
+
+	err = coll.FindOne(ctx, filter).Decode(&doc)
+	return doc, err
+}
+
+// goroutine safe
+//
+// Find returns an Iterator over every document matching filter. The caller
+// must Close it once done.
+func (t *Collection[T]) Find(ctx context.Context, filter bson.M, opts ...options.Lister[options.FindOptions]) (*Iterator[T], error) {
+	s, coll, err := t.ref(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := coll.Find(ctx, filter, opts...)
+	if err != nil {
+		t.c.UnRef(s)
+		return nil, err
+	}
+
+	return &Iterator[T]{c: t.c, s: s, cursor: cursor}, nil
+}
+
+// goroutine safe
+func (t *Collection[T]) UpdateOne(ctx context.Context, filter, update bson.M) (*mongo.UpdateResult, error) {
+	s, coll, err := t.ref(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer t.c.UnRef(s)
+
+	return coll.UpdateOne(ctx, filter, update)
+}
+
+// goroutine safe
+func (t *Collection[T]) DeleteOne(ctx context.Context, filter bson.M) (*mongo.DeleteResult, error) {
+	s, coll, err := t.ref(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer t.c.UnRef(s)
+
+	return coll.DeleteOne(ctx, filter)
+}
+
+// goroutine safe
+func (t *Collection[T]) BulkWrite(ctx context.Context, models []mongo.WriteModel, opts ...options.Lister[options.BulkWriteOptions]) (*mongo.BulkWriteResult, error) {
+	s, coll, err := t.ref(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer t.c.UnRef(s)
+
+	return coll.BulkWrite(ctx, models, opts...)
+}
+
+// Iterator walks the results of a Find call, decoding each document into T.
+type Iterator[T any] struct {
+	c      *ConnectionContext
+	s      *Client
+	cursor *mongo.Cursor
+}
+
+// Next advances the iterator. It returns false once there are no more
+// documents or an error occurred; call Err to distinguish the two.
+func (it *Iterator[T]) Next(ctx context.Context) bool {
+	return it.cursor.Next(ctx)
+}
+
+// Decode decodes the document the iterator currently points to.
+func (it *Iterator[T]) Decode() (T, error) {
+	var doc T
+	err := it.cursor.Decode(&doc)
+	return doc, err
+}
+
+// Err returns the error, if any, that stopped Next.
+func (it *Iterator[T]) Err() error {
+	return it.cursor.Err()
+}
+
+// Close closes the underlying cursor and releases the client back to the
+// pool. It must be called once the caller is done iterating.
+func (it *Iterator[T]) Close(ctx context.Context) error {
+	defer it.c.UnRef(it.s)
+	return it.cursor.Close(ctx)
+}
+
+// ChangeEvent is a decoded MongoDB change stream event.
+type ChangeEvent[T any] struct {
+	OperationType string
+	DocumentKey   bson.M
+	FullDocument  T
+	ResumeToken   bson.Raw
+}
+
+type changeStreamDoc[T any] struct {
+	OperationType string `bson:"operationType"`
+	DocumentKey   bson.M `bson:"documentKey"`
+	FullDocument  T      `bson:"fullDocument"`
+}
+
+// reopen retries coll.Watch with opts until it succeeds or ctx is done,
+// waiting watchReopenDelay between attempts.
+func reopen(ctx context.Context, coll *mongo.Collection, pipeline mongo.Pipeline, opts options.Lister[options.ChangeStreamOptions]) (*mongo.ChangeStream, error) {
+	for {
+		stream, err := coll.Watch(ctx, pipeline, opts)
+		if err == nil {
+			return stream, nil
+		}
+		log.Error("mongodb: reopen change stream failed, retrying: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(watchReopenDelay):
+		}
+	}
+}
+
+// goroutine safe
+//
+// Watch opens a change stream over the collection, decodes FullDocument
+// into T, and delivers events on the returned channel. If the stream is
+// dropped (network blip, failover) it is transparently reopened, retrying
+// every watchReopenDelay until it succeeds, resuming from the last resume
+// token seen so no events are missed. The channel is closed, and the
+// client acquired via Ref released, once ctx is canceled.
+func (t *Collection[T]) Watch(ctx context.Context, pipeline mongo.Pipeline) (<-chan ChangeEvent[T], error) {
+	s, coll, err := t.ref(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := coll.Watch(ctx, pipeline, options.ChangeStream().SetFullDocument(options.UpdateLookup))
+	if err != nil {
+		t.c.UnRef(s)
+		return nil, err
+	}
+
+	out := make(chan ChangeEvent[T])
+
+	go func() {
+		defer close(out)
+		defer t.c.UnRef(s)
+		defer stream.Close(ctx)
+
+		var resumeToken bson.Raw
+
+		for {
+			for stream.Next(ctx) {
+				resumeToken = stream.ResumeToken()
+
+				var doc changeStreamDoc[T]
+				if err := stream.Decode(&doc); err != nil {
+					log.Error("mongodb: decode change event failed: %v", err)
+					continue
+				}
+
+				select {
+				case out <- ChangeEvent[T]{
+					OperationType: doc.OperationType,
+					DocumentKey:   doc.DocumentKey,
+					FullDocument:  doc.FullDocument,
+					ResumeToken:   resumeToken,
+				}:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if err := stream.Err(); err != nil {
+				log.Error("mongodb: change stream error, reconnecting: %v", err)
+			}
+			stream.Close(ctx)
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+			if resumeToken != nil {
+				opts = opts.SetResumeAfter(resumeToken)
+			}
+
+			newStream, err := reopen(ctx, coll, pipeline, opts)
+			if err != nil {
+				// ctx was canceled while retrying.
+				return
+			}
+			stream = newStream
+		}
+	}()
+
+	return out, nil
+}